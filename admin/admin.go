@@ -0,0 +1,174 @@
+// Package admin exposes a small HTTP surface for connection and topic
+// introspection: who is connected and to what, without having to
+// instrument PHP to find out.
+package admin
+
+import (
+	"crypto/hmac"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"go.uber.org/zap"
+)
+
+// SecretHeader carries the shared secret every admin request must present.
+const SecretHeader string = "X-Ws-Admin-Secret"
+
+// ConnectionInfo describes a single live connection.
+type ConnectionInfo struct {
+	ID          string    `json:"id"`
+	RemoteAddr  string    `json:"remoteAddr"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	Topics      []string  `json:"topics"`
+}
+
+// TopicInfo describes a single topic's subscriber count.
+type TopicInfo struct {
+	Topic       string `json:"topic"`
+	Subscribers int    `json:"subscribers"`
+}
+
+// Stats is the aggregate counters backing the websockets_* metrics.
+type Stats struct {
+	ConnectionsActive int    `json:"connectionsActive"`
+	TopicsActive      int    `json:"topicsActive"`
+	MessagesBroadcast uint64 `json:"messagesBroadcastTotal"`
+	UpgradeFailures   uint64 `json:"upgradeFailuresTotal"`
+}
+
+// Backend is what the admin endpoints need from the Plugin.
+type Backend interface {
+	Connections() []ConnectionInfo
+	TopicSubscribers() []TopicInfo
+	Stats() Stats
+	Disconnect(connectionID string) error
+	Broadcast(topic string, payload []byte) error
+}
+
+// Handler serves the admin endpoints behind a shared-secret header.
+type Handler struct {
+	backend Backend
+	secret  string
+	log     *zap.Logger
+	mux     *http.ServeMux
+}
+
+// NewHandler builds the admin HTTP handler. It is meant to be mounted,
+// typically with http.StripPrefix, under ws.admin.path on the same server
+// the websockets middleware runs on.
+func NewHandler(backend Backend, secret string, log *zap.Logger) *Handler {
+	h := &Handler{backend: backend, secret: secret, log: log}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/connections", h.withAuth(h.listConnections))
+	mux.HandleFunc("/topics", h.withAuth(h.listTopics))
+	mux.HandleFunc("/stats", h.withAuth(h.stats))
+	mux.HandleFunc("/disconnect/", h.withAuth(h.disconnect))
+	mux.HandleFunc("/broadcast", h.withAuth(h.broadcast))
+	h.mux = mux
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) withAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.secret == "" || !hmac.Equal([]byte(r.Header.Get(SecretHeader)), []byte(h.secret)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// listConnections streams one JSON object per connection instead of
+// encoding a single array, so the encoded response is never buffered
+// whole. The list of connections itself is still built in full by
+// Backend.Connections before encoding starts.
+func (h *Handler) listConnections(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	for _, c := range h.backend.Connections() {
+		if err := enc.Encode(c); err != nil {
+			h.log.Error("admin: encode connection", zap.Error(err))
+			return
+		}
+	}
+}
+
+func (h *Handler) listTopics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+
+	for _, t := range h.backend.TopicSubscribers() {
+		if err := enc.Encode(t); err != nil {
+			h.log.Error("admin: encode topic", zap.Error(err))
+			return
+		}
+	}
+}
+
+func (h *Handler) stats(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(h.backend.Stats())
+	if err != nil {
+		h.log.Error("admin: encode stats", zap.Error(err))
+	}
+}
+
+func (h *Handler) disconnect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	connectionID := strings.TrimPrefix(r.URL.Path, "/disconnect/")
+	if connectionID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err := h.backend.Disconnect(connectionID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type broadcastRequest struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+}
+
+func (h *Handler) broadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := &broadcastRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil || req.Topic == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = h.backend.Broadcast(req.Topic, req.Payload)
+	if err != nil {
+		h.log.Error("admin: broadcast", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
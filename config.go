@@ -0,0 +1,165 @@
+package websockets
+
+import (
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Config configures the websockets plugin.
+type Config struct {
+	// Broker is the name of the pub-sub driver to use (redis, memory, etc.)
+	Broker string `mapstructure:"broker"`
+
+	// Path is the HTTP path the plugin upgrades connections on.
+	Path string `mapstructure:"path"`
+
+	// AllowedOrigin is the value the Origin header is checked against on upgrade.
+	AllowedOrigin string `mapstructure:"allowed_origin"`
+
+	// Pool configures the PHP worker pool used to validate access.
+	Pool *PoolConfig `mapstructure:"pool"`
+
+	// Auth configures how and how often access is (re-)validated.
+	Auth *AuthConfig `mapstructure:"auth"`
+
+	// Metrics configures the Prometheus metrics this plugin exposes.
+	Metrics *MetricsConfig `mapstructure:"metrics"`
+
+	// Admin configures the connection/topic introspection endpoints.
+	Admin *AdminConfig `mapstructure:"admin"`
+}
+
+// PoolConfig is a thin mirror of the sdk pool config accepted by server.NewWorkerPool.
+type PoolConfig struct {
+	Debug           bool              `mapstructure:"debug"`
+	NumWorkers      uint64            `mapstructure:"num_workers"`
+	MaxJobs         uint64            `mapstructure:"max_jobs"`
+	AllocateTimeout time.Duration     `mapstructure:"allocate_timeout"`
+	DestroyTimeout  time.Duration     `mapstructure:"destroy_timeout"`
+	Supervisor      map[string]string `mapstructure:"supervisor"`
+}
+
+// Access validator modes accepted by AuthConfig.Mode.
+const (
+	AuthModePHP    string = "php"
+	AuthModeJWT    string = "jwt"
+	AuthModeHybrid string = "hybrid"
+)
+
+// AuthConfig configures the access validator, including the periodic re-auth loop.
+type AuthConfig struct {
+	// RecheckInterval is how often a live connection is re-validated against
+	// the access validator. Zero disables the periodic re-auth loop.
+	RecheckInterval time.Duration `mapstructure:"recheck_interval"`
+
+	// RecheckTopics, when true, re-validates every topic the connection is
+	// currently joined to, in addition to the server-level check.
+	RecheckTopics bool `mapstructure:"recheck_topics"`
+
+	// Mode selects the access validator: "php" round-trips through the PHP
+	// pool (the default), "jwt" verifies a signed token locally, and
+	// "hybrid" uses the JWT fast path when a token is present and falls
+	// back to PHP otherwise.
+	Mode string `mapstructure:"mode"`
+
+	// JWT configures the local token validator used by the jwt/hybrid modes.
+	JWT *JWTAuthConfig `mapstructure:"jwt"`
+}
+
+// JWTAuthConfig configures validator.JWTAccessValidator.
+type JWTAuthConfig struct {
+	// Alg is the expected signing algorithm: HS256, RS256 or EdDSA.
+	Alg string `mapstructure:"alg"`
+
+	// Key is the HMAC secret (HS256) or PEM-encoded public key (RS256,
+	// EdDSA) used to verify the token's signature.
+	Key string `mapstructure:"key"`
+
+	// CookieName, when set, is checked for the token if no Authorization
+	// header is present.
+	CookieName string `mapstructure:"cookie_name"`
+
+	// QueryParam, when set, is checked for the token if neither the header
+	// nor the cookie carried one.
+	QueryParam string `mapstructure:"query_param"`
+}
+
+// MetricsConfig configures the Prometheus metrics subsystem.
+type MetricsConfig struct {
+	// Enabled turns the websockets_* collectors on.
+	Enabled bool `mapstructure:"enabled"`
+
+	// TopicAllowlist, when set, is a regex that a topic name must match to
+	// be reported as a websockets_topic_subscribers label. Protects against
+	// unbounded cardinality from untrusted or dynamic topic names.
+	TopicAllowlist string `mapstructure:"topic_allowlist"`
+}
+
+// AdminConfig configures the admin HTTP endpoints.
+type AdminConfig struct {
+	// Path is the path prefix admin endpoints are mounted under, e.g.
+	// "/ws/admin". Empty (the default) disables the admin endpoints.
+	Path string `mapstructure:"path"`
+
+	// Secret must be presented in the X-Ws-Admin-Secret header on every
+	// admin request.
+	Secret string `mapstructure:"secret"`
+}
+
+// InitDefault fills in the defaults for options that were not configured by the user.
+func (c *Config) InitDefault() error {
+	if c.Path == "" {
+		c.Path = "/ws"
+	}
+
+	if c.Pool == nil {
+		c.Pool = &PoolConfig{}
+	}
+
+	if c.Pool.AllocateTimeout == 0 {
+		c.Pool.AllocateTimeout = time.Second * 60
+	}
+
+	if c.Pool.DestroyTimeout == 0 {
+		c.Pool.DestroyTimeout = time.Second * 60
+	}
+
+	if c.Auth == nil {
+		c.Auth = &AuthConfig{}
+	}
+
+	if c.Auth.Mode == "" {
+		c.Auth.Mode = AuthModePHP
+	}
+
+	if c.Auth.JWT == nil {
+		c.Auth.JWT = &JWTAuthConfig{}
+	}
+
+	if c.Auth.JWT.Alg == "" {
+		c.Auth.JWT.Alg = "HS256"
+	}
+
+	if c.Auth.JWT.CookieName == "" {
+		c.Auth.JWT.CookieName = "token"
+	}
+
+	if c.Auth.JWT.QueryParam == "" {
+		c.Auth.JWT.QueryParam = "token"
+	}
+
+	if c.Metrics == nil {
+		c.Metrics = &MetricsConfig{}
+	}
+
+	if c.Admin == nil {
+		c.Admin = &AdminConfig{}
+	}
+
+	if c.Admin.Path != "" && c.Admin.Secret == "" {
+		return errors.Str("ws.admin.secret must be set when ws.admin.path is configured")
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package executor
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/websockets/v2/attributes"
+	"github.com/roadrunner-server/websockets/v2/connection"
+	"github.com/roadrunner-server/websockets/v2/validator"
+	"go.uber.org/zap"
+)
+
+// recheckKey marks a validator call made from the periodic re-auth loop, so
+// that the PHP side (or a custom validator) can tell it apart from the
+// initial upgrade/join checks and, for example, skip side effects that only
+// make sense once.
+const recheckKey string = "ws:recheck"
+
+// StatusAccessRevoked is the close code sent when the periodic recheck loop
+// finds that a live connection's access was revoked. It is a private-use
+// code (RFC 6455 section 7.4.2, range 4000-4999) distinct from the generic
+// ws.StatusPolicyViolation used by Kick, so a client can tell "your session
+// was revoked, re-authenticate" apart from an ordinary forced disconnect.
+const StatusAccessRevoked ws.StatusCode = 4001
+
+// frame is the wire format exchanged with the connected client.
+type frame struct {
+	Command string   `json:"command"`
+	Topics  []string `json:"topics,omitempty"`
+}
+
+// Executor wraps a single upgraded connection and owns its command loop:
+// reading join/leave frames from the client, validating access to the
+// requested topics and keeping the set of joined topics in sync with the
+// underlying pub-sub driver.
+type Executor struct {
+	sync.RWMutex
+
+	log          *zap.Logger
+	conn         *connection.Connection
+	connectionID string
+	subReader    subReader
+	accessValid  validator.AccessValidatorFn
+	request      *http.Request
+	connectedAt  time.Time
+
+	// validateMu serializes every call into accessValid for this connection.
+	// The PHP validator (ServerAccessValidator/TopicsAccessValidator) and
+	// recheckValidate stamp and read attributes on the shared request through
+	// attributes.Set/All, which is not safe to mutate from more than one
+	// goroutine at a time. join/leave run on the client's command-loop
+	// goroutine while the recheck loop and TriggerReauth run on their own,
+	// so without this lock a join racing a recheck tick corrupts the
+	// attributes map.
+	validateMu sync.Mutex
+
+	// topics currently joined by this connection
+	topics map[string]struct{}
+
+	// recheck, when configured, periodically re-validates the connection
+	// and its joined topics against the access validator.
+	recheck *recheckLoop
+
+	stopCh chan struct{}
+	stopO  sync.Once
+}
+
+// subReader is the slice of pubsub.SubReader the executor needs.
+type subReader interface {
+	Subscribe(connectionID string, topics ...string) error
+	Unsubscribe(connectionID string, topics ...string) error
+}
+
+// NewExecutor creates an executor for the just-upgraded connection.
+func NewExecutor(conn *connection.Connection, log *zap.Logger, connectionID string, sr subReader, av validator.AccessValidatorFn, r *http.Request) *Executor {
+	return &Executor{
+		log:          log,
+		conn:         conn,
+		connectionID: connectionID,
+		subReader:    sr,
+		accessValid:  av,
+		request:      r,
+		connectedAt:  time.Now(),
+		topics:       make(map[string]struct{}),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// StartCommandLoop starts the recheck loop (if configured) and blocks,
+// reading and dispatching frames from the client until the connection is
+// closed or an unrecoverable error occurs.
+func (e *Executor) StartCommandLoop() error {
+	const op = errors.Op("executor_start_command_loop")
+
+	if e.recheck != nil {
+		go e.recheck.run(e.stopCh)
+	}
+
+	for {
+		data, err := e.conn.Read()
+		if err != nil {
+			return nil
+		}
+
+		f := &frame{}
+		err = json.Unmarshal(data, f)
+		if err != nil {
+			e.log.Warn("failed to unmarshal client frame", zap.Error(err))
+			continue
+		}
+
+		switch f.Command {
+		case "join":
+			err = e.join(f.Topics...)
+		case "leave":
+			err = e.leave(f.Topics...)
+		default:
+			e.log.Warn("unknown command", zap.String("command", f.Command))
+			continue
+		}
+
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+}
+
+// Deliver writes a broadcast payload straight to the client, bypassing the
+// join/leave command parsing. Used by the pool to fan out broker messages.
+func (e *Executor) Deliver(payload []byte) error {
+	return e.conn.Write(payload)
+}
+
+// Topics returns a snapshot of the topics currently joined by this
+// connection.
+func (e *Executor) Topics() []string {
+	return e.joinedTopics()
+}
+
+// RemoteAddr returns the client's address, as reported by the underlying
+// connection.
+func (e *Executor) RemoteAddr() string {
+	return e.conn.RemoteAddr()
+}
+
+// ConnectedAt returns the time the connection was established.
+func (e *Executor) ConnectedAt() time.Time {
+	return e.connectedAt
+}
+
+// Kick forcibly closes the connection, e.g. in response to a broker-driven
+// control command or a ban issued from PHP.
+func (e *Executor) Kick() error {
+	return e.conn.CloseWithCode(ws.StatusPolicyViolation, "kicked")
+}
+
+// ForceLeave unsubscribes the connection from topics and notifies the
+// client with a LEFT frame, without requiring a client-sent leave command.
+func (e *Executor) ForceLeave(topics ...string) error {
+	return e.leave(topics...)
+}
+
+// TriggerReauth runs a single re-authorization pass immediately, regardless
+// of the configured recheck interval.
+func (e *Executor) TriggerReauth() {
+	go (&recheckLoop{e: e, recheckTopics: true}).tick()
+}
+
+// validate runs the access validator for topics, holding validateMu so it
+// never interleaves with another validator call for the same connection.
+func (e *Executor) validate(topics ...string) (*validator.AccessValidator, error) {
+	e.validateMu.Lock()
+	defer e.validateMu.Unlock()
+
+	return e.accessValid(e.request, topics...)
+}
+
+// recheckValidate is like validate, but marks the call with the ws:recheck
+// attribute first, so a validator can tell a periodic re-auth pass apart
+// from an initial join and, for example, skip side effects that only make
+// sense once. Used by recheckLoop for both the server-level pass and the
+// per-topic rechecks.
+func (e *Executor) recheckValidate(topics ...string) (*validator.AccessValidator, error) {
+	e.validateMu.Lock()
+	defer e.validateMu.Unlock()
+
+	err := attributes.Set(e.request, recheckKey, true)
+	if err != nil {
+		return nil, err
+	}
+	defer delete(attributes.All(e.request), recheckKey)
+
+	return e.accessValid(e.request, topics...)
+}
+
+func (e *Executor) join(topics ...string) error {
+	const op = errors.Op("executor_join")
+
+	val, err := e.validate(topics...)
+	if err != nil || val.Status != http.StatusOK {
+		return e.conn.Write([]byte(`{"topic":"#join","status":403}`))
+	}
+
+	err = e.subReader.Subscribe(e.connectionID, topics...)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	e.Lock()
+	for i := 0; i < len(topics); i++ {
+		e.topics[topics[i]] = struct{}{}
+	}
+	e.Unlock()
+
+	return nil
+}
+
+func (e *Executor) leave(topics ...string) error {
+	err := e.subReader.Unsubscribe(e.connectionID, topics...)
+	if err != nil {
+		return err
+	}
+
+	e.Lock()
+	for i := 0; i < len(topics); i++ {
+		delete(e.topics, topics[i])
+	}
+	e.Unlock()
+
+	return e.sendLeft(topics...)
+}
+
+func (e *Executor) sendLeft(topics ...string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"topic":  "#left",
+		"topics": topics,
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.conn.Write(data)
+}
+
+// joinedTopics returns a snapshot of the topics currently joined by this
+// connection.
+func (e *Executor) joinedTopics() []string {
+	e.RLock()
+	defer e.RUnlock()
+
+	topics := make([]string, 0, len(e.topics))
+	for t := range e.topics {
+		topics = append(topics, t)
+	}
+
+	return topics
+}
+
+// CleanUp unsubscribes the connection from every topic it joined and stops
+// the recheck loop, if any.
+func (e *Executor) CleanUp() {
+	e.stopO.Do(func() {
+		close(e.stopCh)
+	})
+
+	topics := e.joinedTopics()
+	if len(topics) == 0 {
+		return
+	}
+
+	err := e.subReader.Unsubscribe(e.connectionID, topics...)
+	if err != nil {
+		e.log.Error("unsubscribe on cleanup", zap.Error(err))
+	}
+}
+
+// WithRecheck enables the periodic re-authorization loop for this executor.
+// It must be called before StartCommandLoop.
+func (e *Executor) WithRecheck(interval time.Duration, recheckTopics bool) {
+	if interval <= 0 {
+		return
+	}
+
+	e.recheck = &recheckLoop{
+		e:             e,
+		interval:      interval,
+		recheckTopics: recheckTopics,
+	}
+}
@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recheckLoop periodically re-invokes the access validator for a live
+// connection, mirroring the checks performed at upgrade and join time.
+// Credentials can expire mid-session (a banned user, a rotated token); this
+// loop is what notices and acts on that instead of waiting for the next
+// client message.
+type recheckLoop struct {
+	e *Executor
+
+	interval      time.Duration
+	recheckTopics bool
+}
+
+func (r *recheckLoop) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !r.tick() {
+				return
+			}
+		}
+	}
+}
+
+// tick performs a single re-authorization pass. It returns false when the
+// connection was closed and the recheck loop should stop. A validator
+// error (e.g. a PHP pool allocate/exec timeout) is treated as transient and
+// skipped rather than revoking access: only a definite non-200 response
+// means the connection or topic is actually denied.
+func (r *recheckLoop) tick() bool {
+	e := r.e
+
+	val, err := e.recheckValidate()
+	if err != nil {
+		e.log.Warn("recheck: access validator error, will retry next tick",
+			zap.String("connectionID", e.connectionID), zap.Error(err))
+		return true
+	}
+
+	if val.Status != http.StatusOK {
+		e.log.Warn("recheck: server access no longer authorized, closing connection",
+			zap.String("connectionID", e.connectionID))
+
+		_ = e.conn.CloseWithCode(StatusAccessRevoked, "access revoked")
+		return false
+	}
+
+	if !r.recheckTopics {
+		return true
+	}
+
+	topics := e.joinedTopics()
+	if len(topics) == 0 {
+		return true
+	}
+
+	denied := make([]string, 0, len(topics))
+	for i := 0; i < len(topics); i++ {
+		val, err = e.recheckValidate(topics[i])
+		// the PHP topics validator reports a non-200 response together with
+		// an error, so a definite denial is checked before the error
+		if val != nil && val.Status != http.StatusOK {
+			denied = append(denied, topics[i])
+			continue
+		}
+
+		if err != nil {
+			e.log.Warn("recheck: access validator error for topic, will retry next tick",
+				zap.String("connectionID", e.connectionID), zap.String("topic", topics[i]), zap.Error(err))
+			continue
+		}
+	}
+
+	if len(denied) == 0 {
+		return true
+	}
+
+	e.log.Warn("recheck: access to topics revoked, forcing unsubscribe",
+		zap.String("connectionID", e.connectionID), zap.Strings("topics", denied))
+
+	err = e.subReader.Unsubscribe(e.connectionID, denied...)
+	if err != nil {
+		e.log.Error("recheck: unsubscribe denied topics", zap.Error(err))
+	}
+
+	e.Lock()
+	for i := 0; i < len(denied); i++ {
+		delete(e.topics, denied[i])
+	}
+	e.Unlock()
+
+	err = e.sendLeft(denied...)
+	if err != nil {
+		e.log.Error("recheck: send LEFT frame", zap.Error(err))
+	}
+
+	return true
+}
@@ -0,0 +1,128 @@
+// Package control implements the broker-driven forced-disconnect / session
+// invalidation channel: small JSON commands published to a well-known
+// pub-sub topic that let PHP (or an admin tool) revoke a live connection
+// without waiting for its next client message.
+package control
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"go.uber.org/zap"
+)
+
+const topicPrefix string = "ws:control:"
+
+// TopicAll is the wildcard control topic a command can be published to in
+// order to reach every connected client. It only works on brokers that
+// implement PatternSubscriber; on a broker that only matches channel names
+// literally, subscribing to it is a no-op and broadcast-to-all commands
+// must instead be fanned out by the publisher as one message per
+// ConnectionTopic.
+const TopicAll string = topicPrefix + "*"
+
+// PatternSubscriber is implemented by brokers that support subscribing to a
+// glob pattern (e.g. Redis PSUBSCRIBE) rather than only literal channel
+// names. Subscribing to TopicAll through the plain pubsub.SubReader.Subscribe
+// only works if the broker happens to treat Subscribe as a pattern
+// subscription; most brokers don't, so a publish to a per-connection topic
+// like "ws:control:<id>" would silently never reach a plain Subscribe("ws:control:*")
+// listener. Callers should check for this interface before relying on
+// TopicAll and fall back to per-connection topics otherwise.
+type PatternSubscriber interface {
+	PSubscribe(connectionID string, pattern string) error
+}
+
+// ConnectionTopic returns the per-connection control topic for connectionID.
+func ConnectionTopic(connectionID string) string {
+	return topicPrefix + connectionID
+}
+
+// IsControlTopic reports whether topic belongs to the control channel,
+// as opposed to an application topic.
+func IsControlTopic(topic string) bool {
+	return strings.HasPrefix(topic, topicPrefix)
+}
+
+// Op identifies a control command.
+type Op string
+
+const (
+	// OpKick closes the connection.
+	OpKick Op = "kick"
+	// OpLeave forces the connection to leave one or more topics.
+	OpLeave Op = "leave"
+	// OpReauth triggers an immediate re-authorization pass.
+	OpReauth Op = "reauth"
+)
+
+// Command is the JSON payload carried by a control message.
+type Command struct {
+	Op     Op       `json:"op"`
+	Topics []string `json:"topics,omitempty"`
+}
+
+// Target is the slice of an executor.Executor that a control command acts
+// on.
+type Target interface {
+	Kick() error
+	ForceLeave(topics ...string) error
+	TriggerReauth()
+}
+
+// Message is the slice of pubsub.Message that Dispatch needs.
+type Message interface {
+	Topic() string
+	Payload() []byte
+}
+
+// Dispatch decodes msg as a Command and applies it to the connection(s)
+// addressed by msg's topic, looked up in connections (Plugin.connections).
+func Dispatch(connections *sync.Map, msg Message, log *zap.Logger) {
+	cmd := &Command{}
+	err := json.Unmarshal(msg.Payload(), cmd)
+	if err != nil {
+		log.Warn("control: malformed command", zap.String("topic", msg.Topic()), zap.Error(err))
+		return
+	}
+
+	connectionID := strings.TrimPrefix(msg.Topic(), topicPrefix)
+	if connectionID == "*" {
+		connections.Range(func(key, value any) bool {
+			apply(key.(string), value, cmd, log)
+			return true
+		})
+		return
+	}
+
+	v, ok := connections.Load(connectionID)
+	if !ok {
+		log.Debug("control: connection not found", zap.String("connectionID", connectionID))
+		return
+	}
+
+	apply(connectionID, v, cmd, log)
+}
+
+func apply(connectionID string, v any, cmd *Command, log *zap.Logger) {
+	t, ok := v.(Target)
+	if !ok {
+		return
+	}
+
+	switch cmd.Op {
+	case OpKick:
+		if err := t.Kick(); err != nil {
+			log.Error("control: kick", zap.String("connectionID", connectionID), zap.Error(err))
+		}
+	case OpLeave:
+		if err := t.ForceLeave(cmd.Topics...); err != nil {
+			log.Error("control: leave", zap.String("connectionID", connectionID), zap.Error(err))
+		}
+	case OpReauth:
+		t.TriggerReauth()
+	default:
+		log.Warn("control: unknown op", zap.String("op", string(cmd.Op)), zap.String("connectionID", connectionID))
+	}
+}
@@ -0,0 +1,93 @@
+// Package metrics exposes the websockets plugin's Prometheus collectors,
+// wired into the standard RR metrics plugin through MetricsCollector.
+package metrics
+
+import (
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/roadrunner-server/errors"
+)
+
+const namespace string = "websockets"
+
+// Collector holds every Prometheus metric the plugin exposes.
+type Collector struct {
+	topicAllowlist *regexp.Regexp
+
+	ConnectionsActive      prometheus.Gauge
+	TopicSubscribers       *prometheus.GaugeVec
+	MessagesBroadcastTotal prometheus.Counter
+	AccessValidatorSeconds *prometheus.HistogramVec
+	UpgradeFailuresTotal   prometheus.Counter
+}
+
+// NewCollector builds the collector. topicAllowlist, when non-empty, is
+// compiled as a regexp and used by TopicAllowed to keep the
+// topic_subscribers gauge from accumulating unbounded cardinality on
+// untrusted or dynamic topic names.
+func NewCollector(topicAllowlist string) (*Collector, error) {
+	const op = errors.Op("metrics_new_collector")
+
+	var allow *regexp.Regexp
+	if topicAllowlist != "" {
+		var err error
+		allow, err = regexp.Compile(topicAllowlist)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	return &Collector{
+		topicAllowlist: allow,
+
+		ConnectionsActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connections_active",
+			Help:      "Number of currently active websocket connections.",
+		}),
+		TopicSubscribers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "topic_subscribers",
+			Help:      "Number of subscribers per topic.",
+		}, []string{"topic"}),
+		MessagesBroadcastTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_broadcast_total",
+			Help:      "Total number of messages broadcast to subscribers.",
+		}),
+		AccessValidatorSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "access_validator_seconds",
+			Help:      "Latency of access validator invocations.",
+		}, []string{"kind"}),
+		UpgradeFailuresTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upgrade_failures_total",
+			Help:      "Total number of failed websocket upgrade attempts.",
+		}),
+	}, nil
+}
+
+// MetricsCollector implements the metrics plugin's collector contract, so
+// that registering this plugin with the metrics plugin registers these
+// collectors too.
+func (c *Collector) MetricsCollector() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.ConnectionsActive,
+		c.TopicSubscribers,
+		c.MessagesBroadcastTotal,
+		c.AccessValidatorSeconds,
+		c.UpgradeFailuresTotal,
+	}
+}
+
+// TopicAllowed reports whether topic may be used as a topic_subscribers
+// label value.
+func (c *Collector) TopicAllowed(topic string) bool {
+	if c.topicAllowlist == nil {
+		return true
+	}
+
+	return c.topicAllowlist.MatchString(topic)
+}
@@ -3,11 +3,15 @@ package websockets
 import (
 	"context"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gobwas/ws"
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/roadrunner-server/api/v2/payload"
 	"github.com/roadrunner-server/api/v2/plugins/config"
 	"github.com/roadrunner-server/api/v2/plugins/pubsub"
@@ -19,9 +23,12 @@ import (
 	poolImpl "github.com/roadrunner-server/sdk/v2/pool"
 	processImpl "github.com/roadrunner-server/sdk/v2/state/process"
 	"github.com/roadrunner-server/sdk/v2/utils"
+	"github.com/roadrunner-server/websockets/v2/admin"
 	"github.com/roadrunner-server/websockets/v2/attributes"
 	"github.com/roadrunner-server/websockets/v2/connection"
 	"github.com/roadrunner-server/websockets/v2/executor"
+	"github.com/roadrunner-server/websockets/v2/internal/control"
+	"github.com/roadrunner-server/websockets/v2/metrics"
 	wsPool "github.com/roadrunner-server/websockets/v2/pool"
 	"github.com/roadrunner-server/websockets/v2/validator"
 	"go.opentelemetry.io/otel/trace"
@@ -34,6 +41,14 @@ const (
 	RrMode          string = "RR_MODE"
 	RrBroadcastPath string = "RR_BROADCAST_PATH"
 	OriginHeaderKey string = "Origin"
+
+	// controlDispatcherID is the pseudo-connection ID the plugin itself
+	// subscribes under to receive broker-driven control commands.
+	controlDispatcherID string = "ws:control-dispatcher"
+
+	// metricsCollectInterval is how often the connections_active and
+	// topic_subscribers gauges are refreshed.
+	metricsCollectInterval = 15 * time.Second
 )
 
 type Plugin struct {
@@ -68,6 +83,17 @@ type Plugin struct {
 
 	// function used to validate access to the requested resource
 	accessValidator validator.AccessValidatorFn
+
+	// metrics is nil unless ws.metrics.enabled is set
+	metrics *metrics.Collector
+
+	// admin is nil unless ws.admin.path is set
+	admin *admin.Handler
+
+	// aggregate counters, kept independently of Prometheus so /stats works
+	// whether or not ws.metrics.enabled is set
+	messagesBroadcastTotal uint64
+	upgradeFailuresTotal   uint64
 }
 
 func (p *Plugin) Init(cfg config.Configurer, log *zap.Logger, server server.Server, b pubsub.Broadcaster) error {
@@ -86,12 +112,23 @@ func (p *Plugin) Init(cfg config.Configurer, log *zap.Logger, server server.Serv
 		return errors.E(op, err)
 	}
 
+	if p.cfg.Metrics.Enabled {
+		p.metrics, err = metrics.NewCollector(p.cfg.Metrics.TopicAllowlist)
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
 	p.serveExit = make(chan struct{})
 	p.server = server
 	p.log = new(zap.Logger)
 	*p.log = *log
 	p.broadcaster = b
 
+	if p.cfg.Admin.Path != "" {
+		p.admin = admin.NewHandler(p, p.cfg.Admin.Secret, p.log)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	p.ctx = ctx
 	p.cancel = cancel
@@ -119,6 +156,23 @@ func (p *Plugin) Serve() chan error {
 		return errCh
 	}
 
+	// listen on the control channel so PHP (or an admin tool) can kick,
+	// force-leave or reauth a live connection without waiting for its next
+	// client message. Broadcast-to-all (TopicAll) only works if the broker
+	// actually supports pattern subscriptions; plain Subscribe treats it as
+	// a literal channel name and would silently never see a message
+	// published to an individual connection's topic.
+	if ps, ok := p.subReader.(control.PatternSubscriber); ok {
+		err = ps.PSubscribe(controlDispatcherID, control.TopicAll)
+		if err != nil {
+			errCh <- errors.E(op, err)
+			return errCh
+		}
+	} else {
+		p.log.Warn("broker does not support pattern subscriptions, broadcast-to-all control commands are unavailable",
+			zap.String("broker", p.cfg.Broker))
+	}
+
 	go func() {
 		var err error
 		p.Lock()
@@ -137,11 +191,23 @@ func (p *Plugin) Serve() chan error {
 			return
 		}
 
-		p.accessValidator = p.defaultAccessValidator()
+		p.accessValidator = p.buildAccessValidator()
 	}()
 
 	p.workersPool = wsPool.NewWorkersPool(p.subReader, &p.connections, p.log)
 
+	p.workersPool.OnMessage(func(pubsub.Message) {
+		atomic.AddUint64(&p.messagesBroadcastTotal, 1)
+
+		if p.metrics != nil {
+			p.metrics.MessagesBroadcastTotal.Inc()
+		}
+	})
+
+	if p.metrics != nil {
+		go p.collectConnectionMetrics()
+	}
+
 	// we need here only Reader part of the interface
 	go func(ps pubsub.Reader) {
 		for {
@@ -155,6 +221,11 @@ func (p *Plugin) Serve() chan error {
 				return
 			}
 
+			if control.IsControlTopic(data.Topic()) {
+				control.Dispatch(&p.connections, data, p.log)
+				continue
+			}
+
 			p.workersPool.Queue(data)
 		}
 	}(p.subReader)
@@ -176,6 +247,16 @@ func (p *Plugin) Name() string {
 	return PluginName
 }
 
+// MetricsCollector implements the metrics plugin's collector contract. It
+// returns nil (no collectors) when ws.metrics.enabled is false.
+func (p *Plugin) MetricsCollector() []prometheus.Collector {
+	if p.metrics == nil {
+		return nil
+	}
+
+	return p.metrics.MetricsCollector()
+}
+
 func (p *Plugin) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if val, ok := r.Context().Value(utils.OtelTracerNameKey).(string); ok {
@@ -185,6 +266,11 @@ func (p *Plugin) Middleware(next http.Handler) http.Handler {
 			r = r.WithContext(ctx)
 		}
 
+		if p.admin != nil && strings.HasPrefix(r.URL.Path, p.cfg.Admin.Path) {
+			http.StripPrefix(p.cfg.Admin.Path, p.admin).ServeHTTP(w, r)
+			return
+		}
+
 		if r.URL.Path != p.cfg.Path {
 			next.ServeHTTP(w, r)
 			return
@@ -220,18 +306,41 @@ func (p *Plugin) Middleware(next http.Handler) http.Handler {
 		_conn, _, _, err := ws.UpgradeHTTP(r, w)
 		if err != nil {
 			p.log.Error("upgrade connection", zap.Error(err))
+			atomic.AddUint64(&p.upgradeFailuresTotal, 1)
+			if p.metrics != nil {
+				p.metrics.UpgradeFailuresTotal.Inc()
+			}
 			return
 		}
 
 		// construct safe connection protected by mutexes
 		safeConn := connection.NewConnection(_conn, p.log)
-		// generate UUID from the connection
-		connectionID := uuid.NewString()
-		// store connection
-		p.connections.Store(connectionID, safeConn)
+
+		// the validator may assign this connection an identity (e.g. a JWT
+		// sub claim) to register it under instead of a random one
+		connectionID := val.Identity
+		if connectionID == "" {
+			connectionID = uuid.NewString()
+		}
 
 		// Executor wraps a connection to have a safe abstraction
 		e := executor.NewExecutor(safeConn, p.log, connectionID, p.subReader, p.accessValidator, r)
+		// periodically re-validate the connection (and, optionally, its
+		// joined topics) for as long as it stays open
+		e.WithRecheck(p.cfg.Auth.RecheckInterval, p.cfg.Auth.RecheckTopics)
+
+		// store the executor (not the raw connection) so broker-driven
+		// control commands and admin introspection can reach it
+		p.connections.Store(connectionID, e)
+
+		// subscribe to this connection's own control topic, so a targeted
+		// kick/leave/reauth command reaches it even on brokers without
+		// wildcard subscription support
+		err = p.subReader.Subscribe(connectionID, control.ConnectionTopic(connectionID))
+		if err != nil {
+			p.log.Error("control topic subscribe", zap.Error(err))
+		}
+
 		p.log.Debug("websocket client connected", zap.String("uuid", connectionID))
 
 		err = e.StartCommandLoop()
@@ -243,6 +352,11 @@ func (p *Plugin) Middleware(next http.Handler) http.Handler {
 		// when exiting - delete the connection
 		p.connections.Delete(connectionID)
 
+		err = p.subReader.Unsubscribe(connectionID, control.ConnectionTopic(connectionID))
+		if err != nil {
+			p.log.Error("control topic unsubscribe", zap.Error(err))
+		}
+
 		// remove connection from all topics from all pub-sub drivers
 		e.CleanUp()
 
@@ -301,6 +415,207 @@ func (p *Plugin) Reset() error {
 	return nil
 }
 
+// Kick forcibly disconnects the connection identified by connectionID, for
+// in-process callers that want to revoke a session without going through
+// the broker control channel.
+func (p *Plugin) Kick(connectionID string) error {
+	const op = errors.Op("plugin_kick")
+
+	v, ok := p.connections.Load(connectionID)
+	if !ok {
+		return errors.E(op, errors.Errorf("connection not found: %s", connectionID))
+	}
+
+	e, ok := v.(*executor.Executor)
+	if !ok {
+		return errors.E(op, errors.Str("unexpected connection registry entry"))
+	}
+
+	return e.Kick()
+}
+
+// Connections implements admin.Backend.
+func (p *Plugin) Connections() []admin.ConnectionInfo {
+	out := make([]admin.ConnectionInfo, 0)
+
+	p.connections.Range(func(k, v interface{}) bool {
+		e, ok := v.(*executor.Executor)
+		if !ok {
+			return true
+		}
+
+		out = append(out, admin.ConnectionInfo{
+			ID:          k.(string),
+			RemoteAddr:  e.RemoteAddr(),
+			ConnectedAt: e.ConnectedAt(),
+			Topics:      e.Topics(),
+		})
+
+		return true
+	})
+
+	return out
+}
+
+// topicSubscriberCounts returns, for every topic currently joined by at
+// least one connection, how many subscribers the broker reports for it.
+func (p *Plugin) topicSubscriberCounts() map[string]int {
+	topics := make(map[string]struct{})
+
+	p.connections.Range(func(_, v interface{}) bool {
+		if e, ok := v.(*executor.Executor); ok {
+			for _, t := range e.Topics() {
+				topics[t] = struct{}{}
+			}
+		}
+
+		return true
+	})
+
+	out := make(map[string]int, len(topics))
+	for t := range topics {
+		ids := make(map[string]struct{})
+		p.subReader.Connections(t, ids)
+		out[t] = len(ids)
+	}
+
+	return out
+}
+
+// TopicSubscribers implements admin.Backend.
+func (p *Plugin) TopicSubscribers() []admin.TopicInfo {
+	counts := p.topicSubscriberCounts()
+	out := make([]admin.TopicInfo, 0, len(counts))
+
+	for topic, n := range counts {
+		out = append(out, admin.TopicInfo{Topic: topic, Subscribers: n})
+	}
+
+	return out
+}
+
+// Stats implements admin.Backend.
+func (p *Plugin) Stats() admin.Stats {
+	active := 0
+	p.connections.Range(func(_, _ interface{}) bool {
+		active++
+		return true
+	})
+
+	return admin.Stats{
+		ConnectionsActive: active,
+		TopicsActive:      len(p.topicSubscriberCounts()),
+		MessagesBroadcast: atomic.LoadUint64(&p.messagesBroadcastTotal),
+		UpgradeFailures:   atomic.LoadUint64(&p.upgradeFailuresTotal),
+	}
+}
+
+// Disconnect implements admin.Backend.
+func (p *Plugin) Disconnect(connectionID string) error {
+	return p.Kick(connectionID)
+}
+
+// Broadcast implements admin.Backend, publishing payload to topic through
+// the configured broker, the same path PHP-originated broadcasts take.
+func (p *Plugin) Broadcast(topic string, payload []byte) error {
+	const op = errors.Op("plugin_broadcast")
+
+	pub, ok := p.subReader.(pubsub.Publisher)
+	if !ok {
+		return errors.E(op, errors.Str("configured broker does not support publishing"))
+	}
+
+	return pub.Publish(&adminMessage{topic: topic, payload: payload})
+}
+
+// adminMessage is the minimal pubsub.Message implementation needed to
+// publish a message on behalf of the POST /broadcast admin endpoint.
+type adminMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *adminMessage) Topic() string   { return m.topic }
+func (m *adminMessage) Payload() []byte { return m.payload }
+func (m *adminMessage) MarshalBinary() ([]byte, error) {
+	return json.Marshal(struct {
+		Topic   string `json:"topic"`
+		Payload []byte `json:"payload"`
+	}{Topic: m.topic, Payload: m.payload})
+}
+
+// collectConnectionMetrics periodically refreshes the connections_active
+// and topic_subscribers gauges until the plugin is stopped.
+func (p *Plugin) collectConnectionMetrics() {
+	ticker := time.NewTicker(metricsCollectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.updateConnectionMetrics()
+		}
+	}
+}
+
+func (p *Plugin) updateConnectionMetrics() {
+	active := 0
+	p.connections.Range(func(_, _ interface{}) bool {
+		active++
+		return true
+	})
+
+	p.metrics.ConnectionsActive.Set(float64(active))
+
+	// Reset before repopulating so a topic that dropped to zero subscribers
+	// since the last tick loses its gauge entirely, instead of keeping its
+	// last observed value forever.
+	p.metrics.TopicSubscribers.Reset()
+
+	for t, n := range p.topicSubscriberCounts() {
+		if !p.metrics.TopicAllowed(t) {
+			continue
+		}
+
+		p.metrics.TopicSubscribers.WithLabelValues(t).Set(float64(n))
+	}
+}
+
+// buildAccessValidator picks the access validator to use based on
+// ws.auth.mode: the PHP pool (the default), a local JWT check, or a hybrid
+// that only falls back to PHP when the request carries no token.
+func (p *Plugin) buildAccessValidator() validator.AccessValidatorFn {
+	switch p.cfg.Auth.Mode {
+	case AuthModeJWT:
+		return validator.JWTAccessValidator(p.jwtValidatorConfig())
+	case AuthModeHybrid:
+		jwtValidator := validator.JWTAccessValidator(p.jwtValidatorConfig())
+		phpValidator := p.defaultAccessValidator()
+		jwtCfg := p.jwtValidatorConfig()
+
+		return func(r *http.Request, topics ...string) (*validator.AccessValidator, error) {
+			if validator.HasToken(r, jwtCfg) {
+				return jwtValidator(r, topics...)
+			}
+
+			return phpValidator(r, topics...)
+		}
+	default:
+		return p.defaultAccessValidator()
+	}
+}
+
+func (p *Plugin) jwtValidatorConfig() *validator.JWTConfig {
+	return &validator.JWTConfig{
+		Alg:        p.cfg.Auth.JWT.Alg,
+		Key:        []byte(p.cfg.Auth.JWT.Key),
+		CookieName: p.cfg.Auth.JWT.CookieName,
+		QueryParam: p.cfg.Auth.JWT.QueryParam,
+	}
+}
+
 func (p *Plugin) defaultAccessValidator() validator.AccessValidatorFn {
 	return func(r *http.Request, topics ...string) (*validator.AccessValidator, error) {
 		const op = errors.Op("access_validator")
@@ -315,7 +630,9 @@ func (p *Plugin) defaultAccessValidator() validator.AccessValidatorFn {
 				return nil, errors.E(op, err)
 			}
 
+			start := time.Now()
 			val, err := p.exec(ctx)
+			p.observeValidator("server", start)
 			if err != nil {
 				return nil, errors.E(err)
 			}
@@ -328,7 +645,9 @@ func (p *Plugin) defaultAccessValidator() validator.AccessValidatorFn {
 			return nil, errors.E(op, err)
 		}
 
+		start := time.Now()
 		val, err := p.exec(ctx)
+		p.observeValidator("topics", start)
 		if err != nil {
 			return nil, errors.E(op)
 		}
@@ -341,6 +660,16 @@ func (p *Plugin) defaultAccessValidator() validator.AccessValidatorFn {
 	}
 }
 
+// observeValidator records an access validator call's latency under the
+// given kind ("server" or "topics") when metrics are enabled.
+func (p *Plugin) observeValidator(kind string, start time.Time) {
+	if p.metrics == nil {
+		return
+	}
+
+	p.metrics.AccessValidatorSeconds.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
+
 func (p *Plugin) putPld(pld *payload.Payload) {
 	pld.Context = make([]byte, 0, 100)
 	pld.Body = make([]byte, 0, 100)
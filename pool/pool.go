@@ -0,0 +1,97 @@
+// Package pool fans broker messages out to the connections subscribed to
+// their topic.
+package pool
+
+import (
+	"sync"
+
+	"github.com/roadrunner-server/api/v2/plugins/pubsub"
+	"go.uber.org/zap"
+)
+
+// deliverer is the slice of executor.Executor a broadcast needs.
+type deliverer interface {
+	Deliver(payload []byte) error
+}
+
+// WorkersPool delivers messages read off the broker to every connection
+// subscribed to the message's topic.
+type WorkersPool struct {
+	subReader   pubsub.SubReader
+	connections *sync.Map
+	log         *zap.Logger
+
+	queue chan pubsub.Message
+	stop  chan struct{}
+
+	// onMessage, when set, is invoked for every queued message before
+	// delivery. Used by the metrics subsystem to count broadcasts.
+	onMessage func(pubsub.Message)
+}
+
+// NewWorkersPool creates and starts a WorkersPool.
+func NewWorkersPool(subReader pubsub.SubReader, connections *sync.Map, log *zap.Logger) *WorkersPool {
+	wp := &WorkersPool{
+		subReader:   subReader,
+		connections: connections,
+		log:         log,
+		queue:       make(chan pubsub.Message, 100),
+		stop:        make(chan struct{}),
+	}
+
+	go wp.serve()
+
+	return wp
+}
+
+// OnMessage registers fn to be called for every message this pool queues.
+func (wp *WorkersPool) OnMessage(fn func(pubsub.Message)) {
+	wp.onMessage = fn
+}
+
+// Queue enqueues a broker message for delivery to subscribed connections.
+func (wp *WorkersPool) Queue(data pubsub.Message) {
+	wp.queue <- data
+}
+
+// Stop stops the pool.
+func (wp *WorkersPool) Stop() {
+	close(wp.stop)
+}
+
+func (wp *WorkersPool) serve() {
+	for {
+		select {
+		case <-wp.stop:
+			return
+		case msg := <-wp.queue:
+			if wp.onMessage != nil {
+				wp.onMessage(msg)
+			}
+
+			wp.deliver(msg)
+		}
+	}
+}
+
+func (wp *WorkersPool) deliver(msg pubsub.Message) {
+	ids := make(map[string]struct{})
+	wp.subReader.Connections(msg.Topic(), ids)
+
+	for id := range ids {
+		v, ok := wp.connections.Load(id)
+		if !ok {
+			continue
+		}
+
+		d, ok := v.(deliverer)
+		if !ok {
+			continue
+		}
+
+		err := d.Deliver(msg.Payload())
+		if err != nil {
+			wp.log.Error("broadcast delivery failed", zap.String("connectionID", id), zap.Error(err))
+		}
+	}
+}
@@ -22,6 +22,11 @@ type AccessValidator struct {
 	Header http.Header `json:"headers"`
 	Status int         `json:"status"`
 	Body   []byte
+
+	// Identity, when non-empty, is a validator-assigned connection identity
+	// (e.g. a JWT's sub claim) that the caller should use as the connection
+	// registry key instead of generating a random one.
+	Identity string
 }
 
 // Request maps net/http requests to PSR7 compatible structure and managed state of temporary uploaded files.
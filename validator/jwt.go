@@ -0,0 +1,153 @@
+package validator
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/roadrunner-server/errors"
+)
+
+// JWTConfig configures JWTAccessValidator.
+type JWTConfig struct {
+	// Alg is the expected signing algorithm: HS256, RS256 or EdDSA.
+	Alg string
+	// Key is the HS256 secret, or the PEM-encoded public key for RS256/EdDSA.
+	// verificationKey decodes it into the shape jwt/v4 expects for Alg.
+	Key []byte
+	// CookieName, when non-empty, is checked for the token if no
+	// Authorization header is present.
+	CookieName string
+	// QueryParam, when non-empty, is checked for the token if neither the
+	// header nor the cookie carried one.
+	QueryParam string
+}
+
+// claims is the expected shape of a validator token: standard registered
+// claims plus the topics this connection is allowed to join.
+type claims struct {
+	jwt.RegisteredClaims
+	Topics []string `json:"topics"`
+}
+
+// JWTAccessValidator verifies a signed token (HS256/RS256/EdDSA) taken from
+// the Authorization header, a cookie or a query parameter, instead of
+// round-tripping through the PHP pool. On success it returns Status:200
+// with Identity set to the token's subject, for the caller to use as the
+// connection's registry key; on failure it returns Status:403 with a JSON
+// body describing the topics that were denied.
+func JWTAccessValidator(cfg *JWTConfig) AccessValidatorFn {
+	return func(r *http.Request, topics ...string) (*AccessValidator, error) {
+		raw, err := ExtractToken(r, cfg)
+		if err != nil {
+			return forbidden(topics), nil
+		}
+
+		c := &claims{}
+		_, err = jwt.ParseWithClaims(raw, c, func(t *jwt.Token) (interface{}, error) {
+			if t.Method.Alg() != cfg.Alg {
+				return nil, errors.Errorf("unexpected signing method: %s", t.Method.Alg())
+			}
+
+			return verificationKey(cfg)
+		})
+		if err != nil {
+			return forbidden(topics), nil
+		}
+
+		deniedTopics := deniedTopics(c.Topics, topics)
+		if len(deniedTopics) != 0 {
+			return forbidden(deniedTopics), nil
+		}
+
+		return &AccessValidator{Status: http.StatusOK, Identity: c.Subject}, nil
+	}
+}
+
+// verificationKey returns the key to verify a token's signature with, in
+// the shape jwt/v4 expects for cfg.Alg: the raw secret for HS256, or a
+// PEM-decoded public key for RS256/EdDSA. cfg.Key is always stored as the
+// config/PEM bytes; only HS256 treats it as a bare secret.
+func verificationKey(cfg *JWTConfig) (interface{}, error) {
+	switch cfg.Alg {
+	case "HS256":
+		return cfg.Key, nil
+	case "RS256":
+		key, err := jwt.ParseRSAPublicKeyFromPEM(cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		return key, nil
+	case "EdDSA":
+		key, err := jwt.ParseEdPublicKeyFromPEM(cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported signing algorithm: %s", cfg.Alg)
+	}
+}
+
+// ExtractToken pulls the bearer token out of r: Authorization header first,
+// then the configured cookie, then the configured query parameter.
+func ExtractToken(r *http.Request, cfg *JWTConfig) (string, error) {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer "), nil
+	}
+
+	if cfg.CookieName != "" {
+		if c, err := r.Cookie(cfg.CookieName); err == nil && c.Value != "" {
+			return c.Value, nil
+		}
+	}
+
+	if cfg.QueryParam != "" {
+		if tok := r.URL.Query().Get(cfg.QueryParam); tok != "" {
+			return tok, nil
+		}
+	}
+
+	return "", errors.Str("no token present")
+}
+
+// HasToken reports whether r carries a token recognizable to cfg. Used by
+// the "hybrid" auth mode to decide whether to try the JWT fast path at all.
+func HasToken(r *http.Request, cfg *JWTConfig) bool {
+	_, err := ExtractToken(r, cfg)
+	return err == nil
+}
+
+// deniedTopics returns the subset of requested not covered by any glob
+// pattern in allowed (e.g. the pattern "chat.*" matches "chat.general").
+func deniedTopics(allowed, requested []string) []string {
+	var denied []string
+
+	for i := 0; i < len(requested); i++ {
+		ok := false
+		for j := 0; j < len(allowed); j++ {
+			if matched, _ := path.Match(allowed[j], requested[i]); matched {
+				ok = true
+				break
+			}
+		}
+
+		if !ok {
+			denied = append(denied, requested[i])
+		}
+	}
+
+	return denied
+}
+
+func forbidden(topics []string) *AccessValidator {
+	body, _ := json.Marshal(struct {
+		Denied []string `json:"denied"`
+	}{Denied: topics})
+
+	return &AccessValidator{Status: http.StatusForbidden, Body: body}
+}
@@ -0,0 +1,137 @@
+package validator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func pemEncodePublicKey(t *testing.T, pub interface{}) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestJWTAccessValidator_HS256(t *testing.T) {
+	secret := []byte("super-secret")
+	cfg := &JWTConfig{Alg: "HS256", Key: secret}
+	validate := JWTAccessValidator(cfg)
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Topics:           []string{"chat.*"},
+	})
+	signed, err := tok.SignedString(secret)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+signed)
+
+	val, err := validate(r, "chat.general")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, val.Status)
+	require.Equal(t, "user-1", val.Identity, "the token's sub must be threaded back through AccessValidator.Identity")
+}
+
+func TestJWTAccessValidator_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	cfg := &JWTConfig{Alg: "RS256", Key: pemEncodePublicKey(t, &key.PublicKey)}
+	validate := JWTAccessValidator(cfg)
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, &claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Topics:           []string{"chat.*"},
+	})
+	signed, err := tok.SignedString(key)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+signed)
+
+	val, err := validate(r, "chat.general")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, val.Status, "RS256 tokens must validate against the PEM-decoded public key")
+}
+
+func TestJWTAccessValidator_EdDSA(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	cfg := &JWTConfig{Alg: "EdDSA", Key: pemEncodePublicKey(t, pub)}
+	validate := JWTAccessValidator(cfg)
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Topics:           []string{"chat.*"},
+	})
+	signed, err := tok.SignedString(priv)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+signed)
+
+	val, err := validate(r, "chat.general")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, val.Status, "EdDSA tokens must validate against the PEM-decoded public key")
+}
+
+func TestJWTAccessValidator_WrongKeyRejected(t *testing.T) {
+	// An RS256-configured validator must reject a token signed with a
+	// different private key than the one matching the configured public key.
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	cfg := &JWTConfig{Alg: "RS256", Key: pemEncodePublicKey(t, &key.PublicKey)}
+	validate := JWTAccessValidator(cfg)
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, &claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Topics:           []string{"chat.*"},
+	})
+	signed, err := tok.SignedString(other)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Authorization", "Bearer "+signed)
+
+	val, err := validate(r, "chat.general")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, val.Status)
+}
+
+func TestDeniedTopics(t *testing.T) {
+	cases := []struct {
+		name      string
+		allowed   []string
+		requested []string
+		denied    []string
+	}{
+		{name: "exact match", allowed: []string{"chat.general"}, requested: []string{"chat.general"}, denied: nil},
+		{name: "glob match", allowed: []string{"chat.*"}, requested: []string{"chat.general", "chat.random"}, denied: nil},
+		{name: "partial denial", allowed: []string{"chat.*"}, requested: []string{"chat.general", "admin.panel"}, denied: []string{"admin.panel"}},
+		{name: "no allowed topics", allowed: nil, requested: []string{"chat.general"}, denied: []string{"chat.general"}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.denied, deniedTopics(tc.allowed, tc.requested))
+		})
+	}
+}